@@ -0,0 +1,333 @@
+package buildbaron
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/evergreen-ci/evergreen/thirdparty"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"text/template"
+)
+
+// Backend identifies which ticket system a BuildBaronPlugin instance files
+// tickets against.
+type Backend string
+
+const (
+	BackendJira   Backend = "jira"
+	BackendGithub Backend = "github"
+	BackendGitlab Backend = "gitlab"
+)
+
+// FailingTasksField is the JIRA custom field Build Baron always populates
+// with the failing task's display name, independent of whatever a project's
+// YAML config maps in CustomFields. existingTicketsJQL searches on this same
+// field, so it must never be left to per-project configuration.
+const FailingTasksField = "customfield_12950"
+
+// TicketFields holds the data used to render and file a new ticket. Project,
+// IssueType, and CustomFields only apply to the JIRA backend - they come
+// straight from the matched ProjectConfig and are ignored by backends that
+// don't have an equivalent concept.
+type TicketFields struct {
+	Summary      string
+	Description  string
+	Project      string
+	IssueType    string
+	FailingTasks []string
+	CustomFields map[string]interface{}
+	AssigneeId   string
+	ReporterId   string
+}
+
+// TicketResult is the backend-agnostic result of filing or fetching a ticket.
+type TicketResult struct {
+	Key string
+	URL string
+}
+
+// TicketSystem is implemented by each ticket-filing backend that Build Baron
+// can file "Build Failure" style tickets into. bbp.opts.Backend selects which
+// implementation BuildBaronPlugin.ticketSystem() returns.
+type TicketSystem interface {
+	// Configured reports whether this backend has the credentials it needs
+	// to be used, so Configure can fail fast with a clear error otherwise.
+	Configured() bool
+	// Create files a new ticket and returns its key/URL.
+	Create(ticket TicketFields) (*TicketResult, error)
+	// Get fetches an existing ticket by id.
+	Get(id string) (*TicketResult, error)
+	// Prompts lists the extra fields (beyond summary/description) this
+	// backend's ticket form should prompt the user for.
+	Prompts() []string
+}
+
+// Configure validates that the ticket-system backend selected in the
+// plugin's config is actually usable, so misconfiguration is caught at
+// startup rather than the first time a user tries to file a ticket.
+func (bbp *BuildBaronPlugin) Configure() error {
+	ts, err := bbp.ticketSystem()
+	if err != nil {
+		return err
+	}
+	if !ts.Configured() {
+		return fmt.Errorf("build baron backend %q is missing required credentials", bbp.opts.Backend)
+	}
+	return nil
+}
+
+// ticketSystem returns the TicketSystem selected by bbp.opts.Backend.
+func (bbp *BuildBaronPlugin) ticketSystem() (TicketSystem, error) {
+	switch bbp.opts.Backend {
+	case "", BackendJira:
+		return &jiraTicketSystem{bbp: bbp}, nil
+	case BackendGithub:
+		return &githubTicketSystem{bbp: bbp}, nil
+	case BackendGitlab:
+		return &gitlabTicketSystem{bbp: bbp}, nil
+	default:
+		return nil, fmt.Errorf("unrecognized ticket backend %q", bbp.opts.Backend)
+	}
+}
+
+// jiraTicketSystem files tickets into JIRA, using the project key and issue
+// type carried on each TicketFields (taken from the matched ProjectConfig).
+type jiraTicketSystem struct {
+	bbp *BuildBaronPlugin
+}
+
+func (j *jiraTicketSystem) Configured() bool {
+	return j.bbp.opts.Host != "" && j.bbp.opts.Username != "" && j.bbp.opts.Password != ""
+}
+
+func (j *jiraTicketSystem) handler() *thirdparty.JiraHandler {
+	return thirdparty.NewJiraHandler(j.bbp.opts.Host, j.bbp.opts.Username, j.bbp.opts.Password)
+}
+
+func (j *jiraTicketSystem) Create(ticket TicketFields) (*TicketResult, error) {
+	request := map[string]interface{}{}
+	request["project"] = map[string]string{"key": ticket.Project}
+	request["summary"] = ticket.Summary
+	request["issuetype"] = map[string]string{"name": ticket.IssueType}
+	request["assignee"] = map[string]string{"name": ticket.AssigneeId}
+	request["reporter"] = map[string]string{"name": ticket.ReporterId}
+	request["description"] = ticket.Description
+	for field, value := range ticket.CustomFields {
+		request[field] = value
+	}
+	// set last so it always wins over a project's CustomFields - the
+	// duplicate-detection JQL in existingTicketsJQL depends on this field
+	// being populated on every ticket, not just ones whose YAML remembers to.
+	request[FailingTasksField] = ticket.FailingTasks
+
+	result, err := j.handler().CreateTicket(request)
+	if err != nil {
+		return nil, err
+	}
+	return &TicketResult{Key: result.Key, URL: fmt.Sprintf("%v/browse/%v", j.bbp.opts.Host, result.Key)}, nil
+}
+
+func (j *jiraTicketSystem) Get(id string) (*TicketResult, error) {
+	issue, err := j.handler().GetJIRATicket(id)
+	if err != nil {
+		return nil, err
+	}
+	return &TicketResult{Key: issue.Key, URL: fmt.Sprintf("%v/browse/%v", j.bbp.opts.Host, issue.Key)}, nil
+}
+
+func (j *jiraTicketSystem) Prompts() []string {
+	return []string{"assignee"}
+}
+
+// githubAPIBase is the GitHub REST API root. It's a var rather than a
+// constant so tests can point it at an httptest.Server instead of the real
+// GitHub API.
+var githubAPIBase = "https://api.github.com"
+
+// githubTicketSystem files tickets as issues in a GitHub repo, for Evergreen
+// users who don't run a JIRA instance.
+type githubTicketSystem struct {
+	bbp *BuildBaronPlugin
+}
+
+func (g *githubTicketSystem) Configured() bool {
+	return g.bbp.opts.GithubToken != "" && g.bbp.opts.GithubOwner != "" && g.bbp.opts.GithubRepo != ""
+}
+
+func (g *githubTicketSystem) Create(ticket TicketFields) (*TicketResult, error) {
+	url := fmt.Sprintf("%v/repos/%v/%v/issues", githubAPIBase, g.bbp.opts.GithubOwner, g.bbp.opts.GithubRepo)
+	body, err := json.Marshal(map[string]interface{}{
+		"title":  ticket.Summary,
+		"body":   ticket.Description,
+		"labels": []string{"build-failure"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("token %v", g.bbp.opts.GithubToken))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusCreated {
+		return nil, fmt.Errorf("creating GitHub issue: %v: %v", resp.Status, string(respBody))
+	}
+
+	var issue struct {
+		Number  int    `json:"number"`
+		HTMLURL string `json:"html_url"`
+	}
+	if err = json.Unmarshal(respBody, &issue); err != nil {
+		return nil, err
+	}
+	return &TicketResult{Key: fmt.Sprintf("#%v", issue.Number), URL: issue.HTMLURL}, nil
+}
+
+func (g *githubTicketSystem) Get(id string) (*TicketResult, error) {
+	url := fmt.Sprintf("%v/repos/%v/%v/issues/%v", githubAPIBase, g.bbp.opts.GithubOwner, g.bbp.opts.GithubRepo, strings.TrimPrefix(id, "#"))
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("token %v", g.bbp.opts.GithubToken))
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching GitHub issue %v: %v", id, resp.Status)
+	}
+	var issue struct {
+		Number  int    `json:"number"`
+		HTMLURL string `json:"html_url"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&issue); err != nil {
+		return nil, err
+	}
+	return &TicketResult{Key: fmt.Sprintf("#%v", issue.Number), URL: issue.HTMLURL}, nil
+}
+
+func (g *githubTicketSystem) Prompts() []string {
+	return nil
+}
+
+// gitlabTicketSystem files tickets as issues in a GitLab project.
+type gitlabTicketSystem struct {
+	bbp *BuildBaronPlugin
+}
+
+func (g *gitlabTicketSystem) Configured() bool {
+	return g.bbp.opts.GitlabToken != "" && g.bbp.opts.GitlabHost != "" && g.bbp.opts.GitlabProject != ""
+}
+
+func (g *gitlabTicketSystem) Create(ticket TicketFields) (*TicketResult, error) {
+	url := fmt.Sprintf("%v/api/v4/projects/%v/issues", g.bbp.opts.GitlabHost, g.bbp.opts.GitlabProject)
+	body, err := json.Marshal(map[string]interface{}{
+		"title":       ticket.Summary,
+		"description": ticket.Description,
+		"labels":      "build-failure",
+	})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("PRIVATE-TOKEN", g.bbp.opts.GitlabToken)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusCreated {
+		return nil, fmt.Errorf("creating GitLab issue: %v: %v", resp.Status, string(respBody))
+	}
+
+	var issue struct {
+		IID    int    `json:"iid"`
+		WebURL string `json:"web_url"`
+	}
+	if err = json.Unmarshal(respBody, &issue); err != nil {
+		return nil, err
+	}
+	return &TicketResult{Key: fmt.Sprintf("#%v", issue.IID), URL: issue.WebURL}, nil
+}
+
+func (g *gitlabTicketSystem) Get(id string) (*TicketResult, error) {
+	url := fmt.Sprintf("%v/api/v4/projects/%v/issues/%v", g.bbp.opts.GitlabHost, g.bbp.opts.GitlabProject, strings.TrimPrefix(id, "#"))
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("PRIVATE-TOKEN", g.bbp.opts.GitlabToken)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching GitLab issue %v: %v", id, resp.Status)
+	}
+	var issue struct {
+		IID    int    `json:"iid"`
+		WebURL string `json:"web_url"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&issue); err != nil {
+		return nil, err
+	}
+	return &TicketResult{Key: fmt.Sprintf("#%v", issue.IID), URL: issue.WebURL}, nil
+}
+
+func (g *gitlabTicketSystem) Prompts() []string {
+	return nil
+}
+
+// descriptionTemplateFor returns the backend-appropriate description
+// template: JIRA wiki markup for the JIRA backend, GitHub-flavored Markdown
+// for GitHub and GitLab, which both render GFM in their issue bodies.
+func descriptionTemplateFor(backend Backend) *template.Template {
+	switch backend {
+	case BackendGithub, BackendGitlab:
+		return MarkdownDescriptionTemplate
+	default:
+		return DescriptionTemplate
+	}
+}
+
+const MarkdownDescriptionTemplateString = `
+## [{{.Task.DisplayName}} failed on {{.Task.BuildVariant}}](` + UIRoot + `/task/{{.Task.Id}})
+
+{{range .Tests}}* **{{.Name}}** - [Logs]({{.URL}}) | [History]({{.HistoryURL}})
+{{if .Snippet}}
+{{.Snippet}}
+{{end}}
+{{end}}
+{{.ClusterSection}}
+
+_BF Ticket Generated by {{.UserId}}_
+`
+
+var MarkdownDescriptionTemplate = template.Must(template.New("MarkdownDesc").Parse(MarkdownDescriptionTemplateString))
@@ -0,0 +1,186 @@
+package buildbaron
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestJiraTicketSystemConfigured(t *testing.T) {
+	bbp := &BuildBaronPlugin{}
+	j := &jiraTicketSystem{bbp: bbp}
+	if j.Configured() {
+		t.Error("Configured() = true for a plugin with no JIRA credentials")
+	}
+	bbp.opts.Host = "https://jira.example.com"
+	bbp.opts.Username = "user"
+	bbp.opts.Password = "pass"
+	if !j.Configured() {
+		t.Error("Configured() = false once host/username/password are set")
+	}
+}
+
+func TestGithubTicketSystemConfigured(t *testing.T) {
+	bbp := &BuildBaronPlugin{}
+	g := &githubTicketSystem{bbp: bbp}
+	if g.Configured() {
+		t.Error("Configured() = true for a plugin with no GitHub credentials")
+	}
+	bbp.opts.GithubToken = "tok"
+	bbp.opts.GithubOwner = "evergreen-ci"
+	bbp.opts.GithubRepo = "evergreen"
+	if !g.Configured() {
+		t.Error("Configured() = false once token/owner/repo are set")
+	}
+}
+
+func TestGitlabTicketSystemConfigured(t *testing.T) {
+	bbp := &BuildBaronPlugin{}
+	g := &gitlabTicketSystem{bbp: bbp}
+	if g.Configured() {
+		t.Error("Configured() = true for a plugin with no GitLab credentials")
+	}
+	bbp.opts.GitlabToken = "tok"
+	bbp.opts.GitlabHost = "https://gitlab.example.com"
+	bbp.opts.GitlabProject = "123"
+	if !g.Configured() {
+		t.Error("Configured() = false once token/host/project are set")
+	}
+}
+
+func TestGithubTicketSystemCreate(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got, want := r.URL.Path, "/repos/evergreen-ci/evergreen/issues"; got != want {
+			t.Errorf("request path = %q, want %q", got, want)
+		}
+		if got, want := r.Header.Get("Authorization"), "token tok"; got != want {
+			t.Errorf("Authorization header = %q, want %q", got, want)
+		}
+		var body struct {
+			Title string `json:"title"`
+			Body  string `json:"body"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Fatalf("decoding request body: %v", err)
+		}
+		if body.Title != "my summary" {
+			t.Errorf("request title = %q, want %q", body.Title, "my summary")
+		}
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"number":   42,
+			"html_url": "https://github.com/evergreen-ci/evergreen/issues/42",
+		})
+	}))
+	defer server.Close()
+	defer func(orig string) { githubAPIBase = orig }(githubAPIBase)
+	githubAPIBase = server.URL
+
+	bbp := &BuildBaronPlugin{}
+	bbp.opts.GithubToken = "tok"
+	bbp.opts.GithubOwner = "evergreen-ci"
+	bbp.opts.GithubRepo = "evergreen"
+	g := &githubTicketSystem{bbp: bbp}
+
+	result, err := g.Create(TicketFields{Summary: "my summary", Description: "my description"})
+	if err != nil {
+		t.Fatalf("Create returned error: %v", err)
+	}
+	if result.Key != "#42" {
+		t.Errorf("result.Key = %q, want %q", result.Key, "#42")
+	}
+	if want := "https://github.com/evergreen-ci/evergreen/issues/42"; result.URL != want {
+		t.Errorf("result.URL = %q, want %q", result.URL, want)
+	}
+}
+
+func TestGithubTicketSystemCreateErrorsOnNon201(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("boom"))
+	}))
+	defer server.Close()
+	defer func(orig string) { githubAPIBase = orig }(githubAPIBase)
+	githubAPIBase = server.URL
+
+	bbp := &BuildBaronPlugin{}
+	g := &githubTicketSystem{bbp: bbp}
+	if _, err := g.Create(TicketFields{Summary: "x"}); err == nil {
+		t.Fatal("expected an error from a 500 response, got nil")
+	}
+}
+
+func TestGithubTicketSystemGet(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got, want := r.URL.Path, "/repos/evergreen-ci/evergreen/issues/42"; got != want {
+			t.Errorf("request path = %q, want %q", got, want)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"number":   42,
+			"html_url": "https://github.com/evergreen-ci/evergreen/issues/42",
+		})
+	}))
+	defer server.Close()
+	defer func(orig string) { githubAPIBase = orig }(githubAPIBase)
+	githubAPIBase = server.URL
+
+	bbp := &BuildBaronPlugin{}
+	bbp.opts.GithubOwner = "evergreen-ci"
+	bbp.opts.GithubRepo = "evergreen"
+	g := &githubTicketSystem{bbp: bbp}
+
+	result, err := g.Get("#42")
+	if err != nil {
+		t.Fatalf("Get returned error: %v", err)
+	}
+	if result.Key != "#42" {
+		t.Errorf("result.Key = %q, want %q", result.Key, "#42")
+	}
+}
+
+func TestGitlabTicketSystemCreate(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got, want := r.URL.Path, "/api/v4/projects/123/issues"; got != want {
+			t.Errorf("request path = %q, want %q", got, want)
+		}
+		if got, want := r.Header.Get("PRIVATE-TOKEN"), "tok"; got != want {
+			t.Errorf("PRIVATE-TOKEN header = %q, want %q", got, want)
+		}
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"iid":     7,
+			"web_url": "https://gitlab.example.com/evergreen-ci/evergreen/issues/7",
+		})
+	}))
+	defer server.Close()
+
+	bbp := &BuildBaronPlugin{}
+	bbp.opts.GitlabToken = "tok"
+	bbp.opts.GitlabHost = server.URL
+	bbp.opts.GitlabProject = "123"
+	g := &gitlabTicketSystem{bbp: bbp}
+
+	result, err := g.Create(TicketFields{Summary: "my summary", Description: "my description"})
+	if err != nil {
+		t.Fatalf("Create returned error: %v", err)
+	}
+	if result.Key != "#7" {
+		t.Errorf("result.Key = %q, want %q", result.Key, "#7")
+	}
+}
+
+func TestGitlabTicketSystemCreateErrorsOnNon201(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("boom"))
+	}))
+	defer server.Close()
+
+	bbp := &BuildBaronPlugin{}
+	bbp.opts.GitlabHost = server.URL
+	g := &gitlabTicketSystem{bbp: bbp}
+	if _, err := g.Create(TicketFields{Summary: "x"}); err == nil {
+		t.Fatal("expected an error from a 400 response, got nil")
+	}
+}
@@ -0,0 +1,276 @@
+package buildbaron
+
+import (
+	"fmt"
+	"github.com/evergreen-ci/evergreen/model"
+	"github.com/evergreen-ci/evergreen/plugin"
+	"github.com/gorilla/mux"
+	"net/http"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// historyLookbackDays bounds how far back we search for similar past
+// failures when clustering.
+const historyLookbackDays = 14
+
+// similarityThreshold is the minimum Jaccard similarity between two tasks'
+// failing-test sets for them to be considered the same failure.
+const similarityThreshold = 0.5
+
+// maxSuggestedMatches caps how many similar failures we surface in a ticket.
+const maxSuggestedMatches = 3
+
+var (
+	numericSuffixRE = regexp.MustCompile(`[_\-]?\d+$`)
+	hexHashRE       = regexp.MustCompile(`[_\-]?[0-9a-fA-F]{6,}$`)
+)
+
+// RevisionResolver finds who most recently touched the code behind a failing
+// test, so clustering can suggest an owner. It is an interface so tests can
+// supply a fake instead of doing real git-blame lookups.
+type RevisionResolver interface {
+	// BlameAuthor returns the author of the most recent commit to modify a
+	// file relevant to testName in the given revision's history.
+	BlameAuthor(project, revision, testName string) (string, error)
+}
+
+// similarFailure is one historical task whose failing tests are judged
+// similar enough to the current failure to be worth surfacing.
+type similarFailure struct {
+	TaskId      string  `json:"task_id"`
+	DisplayName string  `json:"display_name"`
+	URL         string  `json:"url"`
+	Similarity  float64 `json:"similarity"`
+	// Revision is the candidate task's revision, carried along so
+	// suggestOwner can blame it without re-fetching the task.
+	Revision string `json:"-"`
+}
+
+// clusterResult is the suggested-owner and similar-failures summary attached
+// to a new ticket, and returned by the cluster endpoint.
+type clusterResult struct {
+	SuggestedOwner string           `json:"suggested_owner,omitempty"`
+	Similar        []similarFailure `json:"similar"`
+}
+
+// normalizeTestName extends cleanTestName by stripping a trailing numeric
+// suffix (e.g. a retry count or shard index) or hex hash (e.g. a content
+// hash appended to a generated test name), so that failures of the same
+// logical test cluster together even when their names aren't identical.
+func normalizeTestName(path string) string {
+	name := cleanTestName(path)
+	name = hexHashRE.ReplaceAllString(name, "")
+	name = numericSuffixRE.ReplaceAllString(name, "")
+	return name
+}
+
+// jaccardSimilarity returns |a ∩ b| / |a ∪ b| for two sets of normalized
+// test names.
+func jaccardSimilarity(a, b []string) float64 {
+	setA := map[string]bool{}
+	for _, n := range a {
+		setA[normalizeTestName(n)] = true
+	}
+	setB := map[string]bool{}
+	for _, n := range b {
+		setB[normalizeTestName(n)] = true
+	}
+	if len(setA) == 0 && len(setB) == 0 {
+		return 1
+	}
+	intersection := 0
+	union := map[string]bool{}
+	for n := range setA {
+		union[n] = true
+		if setB[n] {
+			intersection++
+		}
+	}
+	for n := range setB {
+		union[n] = true
+	}
+	if len(union) == 0 {
+		return 0
+	}
+	return float64(intersection) / float64(len(union))
+}
+
+// clusterFailure looks for recent tasks whose failures look like the same
+// underlying issue as t's, and ranks a suggested owner for a new ticket.
+func clusterFailure(t *model.Task, tests []jiraTestFailure, resolver RevisionResolver) (*clusterResult, error) {
+	failingTestNames := make([]string, 0, len(tests))
+	for _, test := range tests {
+		failingTestNames = append(failingTestNames, test.Name)
+	}
+
+	// model.FindTasksForProjectAndDisplayName already narrows candidates to
+	// the same project and display name; the Jaccard check below is what
+	// decides whether two tasks' failing-test sets are similar enough, so it
+	// must run against that whole candidate pool rather than only ones whose
+	// failing tests happen to be identical - otherwise a historical task
+	// with one extra flaky failure would never be considered "similar" even
+	// at Jaccard 0.9.
+	since := time.Now().AddDate(0, 0, -historyLookbackDays)
+	candidates, err := model.FindTasksForProjectAndDisplayName(t.Project, t.DisplayName, since)
+	if err != nil {
+		return nil, fmt.Errorf("finding historical failures: %v", err)
+	}
+
+	matches := []similarFailure{}
+	for _, candidate := range candidates {
+		if candidate.Id == t.Id {
+			continue
+		}
+		candidateTestNames := []string{}
+		for _, test := range candidate.TestResults {
+			if test.Status == "fail" {
+				candidateTestNames = append(candidateTestNames, cleanTestName(test.TestFile))
+			}
+		}
+		similarity := jaccardSimilarity(failingTestNames, candidateTestNames)
+		if similarity < similarityThreshold {
+			continue
+		}
+		matches = append(matches, similarFailure{
+			TaskId:      candidate.Id,
+			DisplayName: candidate.DisplayName,
+			URL:         fmt.Sprintf("%v/task/%v", UIRoot, candidate.Id),
+			Similarity:  similarity,
+			Revision:    candidate.Revision,
+		})
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i].Similarity > matches[j].Similarity })
+	if len(matches) > maxSuggestedMatches {
+		matches = matches[:maxSuggestedMatches]
+	}
+
+	result := &clusterResult{Similar: matches}
+	if resolver != nil && len(matches) > 0 {
+		result.SuggestedOwner = suggestOwner(t.Project, matches, failingTestNames, resolver)
+	}
+	return result, nil
+}
+
+// suggestOwner ranks candidate owners by how often they're the git-blame
+// author of the most-recently-modified file touching the failing tests
+// across the matched revisions, and returns the most frequent author. It
+// trusts the revisions already carried on matches rather than re-fetching
+// each candidate task, both to avoid a redundant lookup and so it only
+// depends on its RevisionResolver argument and is easy to unit test.
+func suggestOwner(project string, matches []similarFailure, testNames []string, resolver RevisionResolver) string {
+	counts := map[string]int{}
+	for _, match := range matches {
+		if match.Revision == "" {
+			continue
+		}
+		for _, testName := range testNames {
+			author, err := resolver.BlameAuthor(project, match.Revision, testName)
+			if err != nil || author == "" {
+				continue
+			}
+			counts[author]++
+		}
+	}
+
+	var best string
+	bestCount := 0
+	for author, count := range counts {
+		if count > bestCount {
+			best = author
+			bestCount = count
+		}
+	}
+	return best
+}
+
+// similarFailuresSection renders the "Similar recent failures" and
+// "Suggested owner" sections attached to a new ticket's description, in
+// either JIRA wiki markup or GitHub-flavored Markdown.
+func similarFailuresSection(backend Backend, result *clusterResult) string {
+	if result == nil || len(result.Similar) == 0 {
+		return ""
+	}
+
+	heading := "h3. %v"
+	link := "[%v|%v]"
+	if backend == BackendGithub || backend == BackendGitlab {
+		heading = "### %v"
+		link = "[%v](%v)"
+	}
+
+	lines := []string{"", fmt.Sprintf(heading, "Similar recent failures"), ""}
+	for _, match := range result.Similar {
+		lines = append(lines, fmt.Sprintf(link, match.TaskId, match.URL))
+	}
+	if result.SuggestedOwner != "" {
+		lines = append(lines, "", fmt.Sprintf(heading, "Suggested owner"), "", result.SuggestedOwner)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// gitBlameResolver is the default RevisionResolver, shelling out to git
+// against a local checkout of a single project to find the most recent
+// author of a file touching testName. The caller is responsible for handing
+// it the repo checkout for the right project - it doesn't use its
+// BlameAuthor project argument itself, since repoPath already is that
+// project's checkout.
+type gitBlameResolver struct {
+	repoPath string
+}
+
+func (g *gitBlameResolver) BlameAuthor(project, revision, testName string) (string, error) {
+	out, err := exec.Command("git", "-C", g.repoPath, "log", "-1", "--format=%ae", revision, "--", "*"+testName+"*").Output()
+	if err != nil {
+		return "", fmt.Errorf("running git log for %v: %v", testName, err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// revisionResolver returns the RevisionResolver used to suggest an owner for
+// tickets filed against the given Evergreen project, preferring that
+// project's own repo checkout (ProjectConfig.RepoPath) over the plugin-wide
+// default so multi-project deployments don't blame the wrong repository.
+func (bbp *BuildBaronPlugin) revisionResolver(project string) RevisionResolver {
+	repoPath := bbp.opts.RepoPath
+	if cfg, ok := bbp.opts.Projects[project]; ok && cfg.RepoPath != "" {
+		repoPath = cfg.RepoPath
+	}
+	if repoPath == "" {
+		return nil
+	}
+	return &gitBlameResolver{repoPath: repoPath}
+}
+
+// clusterHandler handles GET /plugin/buildbaron/cluster/:task_id, returning
+// the suggested owner and similar recent failures for a task's current test
+// failures.
+func (bbp *BuildBaronPlugin) clusterHandler(w http.ResponseWriter, r *http.Request) {
+	taskId := mux.Vars(r)["task_id"]
+	t, err := model.FindTask(taskId)
+	if err != nil {
+		plugin.WriteJSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if t == nil {
+		plugin.WriteJSON(w, http.StatusNotFound, fmt.Sprintf("task not found for id %v", taskId))
+		return
+	}
+
+	tests := []jiraTestFailure{}
+	for _, test := range t.TestResults {
+		if test.Status == "fail" {
+			tests = append(tests, jiraTestFailure{Name: cleanTestName(test.TestFile)})
+		}
+	}
+
+	result, err := clusterFailure(t, tests, bbp.revisionResolver(t.Project))
+	if err != nil {
+		plugin.WriteJSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	plugin.WriteJSON(w, http.StatusOK, result)
+}
@@ -0,0 +1,108 @@
+package buildbaron
+
+import (
+	"testing"
+)
+
+func TestNormalizeTestName(t *testing.T) {
+	cases := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"plain", "foo_test", "foo_test"},
+		{"path", "a/b/foo_test", "foo_test"},
+		{"windows path", `a\b\foo_test`, "foo_test"},
+		{"numeric suffix", "foo_test_3", "foo_test"},
+		{"hex hash suffix", "foo_test_deadbeef", "foo_test"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := normalizeTestName(c.in); got != c.want {
+				t.Errorf("normalizeTestName(%q) = %q, want %q", c.in, got, c.want)
+			}
+		})
+	}
+}
+
+func TestJaccardSimilarity(t *testing.T) {
+	cases := []struct {
+		name string
+		a, b []string
+		want float64
+	}{
+		{"identical sets", []string{"foo", "bar"}, []string{"foo", "bar"}, 1},
+		{"disjoint sets", []string{"foo"}, []string{"bar"}, 0},
+		{"one extra flaky test", []string{"foo_test", "bar_test"}, []string{"foo_test", "bar_test", "baz_test"}, 2.0 / 3.0},
+		{"both empty", []string{}, []string{}, 1},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := jaccardSimilarity(c.a, c.b)
+			if got != c.want {
+				t.Errorf("jaccardSimilarity(%v, %v) = %v, want %v", c.a, c.b, got, c.want)
+			}
+		})
+	}
+}
+
+// fakeRevisionResolver is a RevisionResolver that returns a canned author
+// per (revision, testName) pair instead of shelling out to git, so
+// suggestOwner can be tested without a real checkout.
+type fakeRevisionResolver struct {
+	authors map[string]string // keyed by revision+"/"+testName
+}
+
+func (f *fakeRevisionResolver) BlameAuthor(project, revision, testName string) (string, error) {
+	return f.authors[revision+"/"+testName], nil
+}
+
+func TestSuggestOwnerReturnsMostFrequentBlameAuthor(t *testing.T) {
+	resolver := &fakeRevisionResolver{authors: map[string]string{
+		"rev1/foo_test": "alice",
+		"rev2/foo_test": "alice",
+		"rev3/foo_test": "bob",
+	}}
+	matches := []similarFailure{
+		{TaskId: "t1", Revision: "rev1"},
+		{TaskId: "t2", Revision: "rev2"},
+		{TaskId: "t3", Revision: "rev3"},
+	}
+	got := suggestOwner("my_project", matches, []string{"foo_test"}, resolver)
+	if want := "alice"; got != want {
+		t.Errorf("suggestOwner() = %q, want %q", got, want)
+	}
+}
+
+func TestSuggestOwnerSkipsMatchesWithoutARevision(t *testing.T) {
+	resolver := &fakeRevisionResolver{authors: map[string]string{"rev1/foo_test": "alice"}}
+	matches := []similarFailure{
+		{TaskId: "t1", Revision: "rev1"},
+		{TaskId: "t2", Revision: ""},
+	}
+	got := suggestOwner("my_project", matches, []string{"foo_test"}, resolver)
+	if want := "alice"; got != want {
+		t.Errorf("suggestOwner() = %q, want %q", got, want)
+	}
+}
+
+func TestSuggestOwnerReturnsEmptyStringWhenNoAuthorsFound(t *testing.T) {
+	resolver := &fakeRevisionResolver{authors: map[string]string{}}
+	matches := []similarFailure{{TaskId: "t1", Revision: "rev1"}}
+	got := suggestOwner("my_project", matches, []string{"foo_test"}, resolver)
+	if got != "" {
+		t.Errorf("suggestOwner() = %q, want empty string", got)
+	}
+}
+
+func TestJaccardSimilarityMeetsThresholdWithExtraFailure(t *testing.T) {
+	// regression test: a historical task with one extra flaky failure on
+	// top of the current failure set must still clear similarityThreshold.
+	similarity := jaccardSimilarity(
+		[]string{"foo_test", "bar_test"},
+		[]string{"foo_test", "bar_test", "baz_test"},
+	)
+	if similarity < similarityThreshold {
+		t.Errorf("expected similarity %v to meet threshold %v", similarity, similarityThreshold)
+	}
+}
@@ -0,0 +1,102 @@
+package buildbaron
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/10gen-labs/slogger/v1"
+	"github.com/evergreen-ci/evergreen"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// defaultLogTailLines is how many lines of a failing test's log are fetched
+// and attached to its ticket when the request doesn't override it.
+const defaultLogTailLines = 50
+
+// inlineSnippetLines is how much of the fetched tail gets inlined directly
+// into the ticket description, as a quick-glance preview of the attachment.
+const inlineSnippetLines = 20
+
+// logFetchTimeout bounds how long fetchLogTail waits on a single test's log.
+// fileTicket fetches logs serially, so a hung log server must time out
+// rather than stall the whole request - log fetching is best-effort and
+// should never block ticket creation.
+const logFetchTimeout = 10 * time.Second
+
+var logFetchClient = &http.Client{Timeout: logFetchTimeout}
+
+// logTailLines returns the number of log lines to fetch per test, defaulting
+// to defaultLogTailLines unless the plugin config overrides it.
+func logTailLines(bbp *BuildBaronPlugin) int {
+	if bbp.opts.LogTailLines > 0 {
+		return bbp.opts.LogTailLines
+	}
+	return defaultLogTailLines
+}
+
+// fetchLogTail streams a test's log from its Evergreen log URL and returns
+// the last n lines, without holding the whole log in memory at once.
+func fetchLogTail(logURL string, n int) ([]string, error) {
+	resp, err := logFetchClient.Get(logURL)
+	if err != nil {
+		return nil, fmt.Errorf("fetching log %v: %v", logURL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching log %v: %v", logURL, resp.Status)
+	}
+	return tailLines(resp.Body, n)
+}
+
+// tailLines returns the last n lines read from r.
+func tailLines(r io.Reader, n int) ([]string, error) {
+	tail := make([]string, 0, n)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		tail = append(tail, scanner.Text())
+		if len(tail) > n {
+			tail = tail[1:]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return tail, nil
+}
+
+// attachTestLogs uploads each failing test's already-fetched log tail to the
+// newly-filed JIRA issue as "<testname>.log". It's best-effort: a single
+// test's log failing to upload is logged and skipped rather than failing
+// ticket creation, since the ticket already exists by this point.
+func attachTestLogs(jiraHandler *jiraTicketSystem, issueKey string, tests []jiraTestFailure) {
+	for _, test := range tests {
+		if len(test.LogTail) == 0 {
+			continue
+		}
+		reader := strings.NewReader(strings.Join(test.LogTail, "\n"))
+		if err := jiraHandler.handler().AddAttachment(issueKey, test.Name+".log", reader); err != nil {
+			evergreen.Logger.Logf(slogger.WARN, fmt.Sprintf("error attaching log for %v to %v: %v", test.Name, issueKey, err))
+		}
+	}
+}
+
+// logSnippet renders the last inlineSnippetLines lines of tail as a
+// backend-appropriate fenced code block, for inlining directly into a
+// ticket's description. Returns "" if tail is empty, e.g. because the log
+// fetch failed - description rendering must never block on missing logs.
+func logSnippet(backend Backend, tail []string) string {
+	if len(tail) == 0 {
+		return ""
+	}
+	if len(tail) > inlineSnippetLines {
+		tail = tail[len(tail)-inlineSnippetLines:]
+	}
+	body := strings.Join(tail, "\n")
+	if backend == BackendGithub || backend == BackendGitlab {
+		return fmt.Sprintf("```\n%v\n```", body)
+	}
+	return fmt.Sprintf("{code}\n%v\n{code}", body)
+}
@@ -0,0 +1,80 @@
+package buildbaron
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTailLines(t *testing.T) {
+	cases := []struct {
+		name string
+		in   string
+		n    int
+		want []string
+	}{
+		{"fewer lines than n", "a\nb\nc", 5, []string{"a", "b", "c"}},
+		{"exactly n lines", "a\nb\nc", 3, []string{"a", "b", "c"}},
+		{"more lines than n keeps the tail", "a\nb\nc\nd", 2, []string{"c", "d"}},
+		{"empty input", "", 3, []string{}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := tailLines(strings.NewReader(c.in), c.n)
+			if err != nil {
+				t.Fatalf("tailLines returned error: %v", err)
+			}
+			if len(got) != len(c.want) {
+				t.Fatalf("tailLines() = %v, want %v", got, c.want)
+			}
+			for i := range c.want {
+				if got[i] != c.want[i] {
+					t.Errorf("tailLines()[%d] = %q, want %q", i, got[i], c.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestLogSnippet(t *testing.T) {
+	t.Run("empty tail renders nothing", func(t *testing.T) {
+		if got := logSnippet(BackendJira, nil); got != "" {
+			t.Errorf("logSnippet() = %q, want empty string", got)
+		}
+	})
+
+	t.Run("jira uses code macro", func(t *testing.T) {
+		got := logSnippet(BackendJira, []string{"a", "b"})
+		want := "{code}\na\nb\n{code}"
+		if got != want {
+			t.Errorf("logSnippet() = %q, want %q", got, want)
+		}
+	})
+
+	t.Run("github uses a fenced code block", func(t *testing.T) {
+		got := logSnippet(BackendGithub, []string{"a", "b"})
+		want := "```\na\nb\n```"
+		if got != want {
+			t.Errorf("logSnippet() = %q, want %q", got, want)
+		}
+	})
+
+	t.Run("gitlab uses a fenced code block", func(t *testing.T) {
+		got := logSnippet(BackendGitlab, []string{"a"})
+		want := "```\na\n```"
+		if got != want {
+			t.Errorf("logSnippet() = %q, want %q", got, want)
+		}
+	})
+
+	t.Run("truncates to the last inlineSnippetLines lines", func(t *testing.T) {
+		tail := make([]string, inlineSnippetLines+5)
+		for i := range tail {
+			tail[i] = string(rune('a' + i%26))
+		}
+		got := logSnippet(BackendJira, tail)
+		want := "{code}\n" + strings.Join(tail[5:], "\n") + "\n{code}"
+		if got != want {
+			t.Errorf("logSnippet() did not truncate to the last %v lines", inlineSnippetLines)
+		}
+	})
+}
@@ -0,0 +1,123 @@
+package buildbaron
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/evergreen-ci/evergreen/model"
+	"text/template"
+)
+
+// FieldSpec describes how to compute one JIRA custom field's value when
+// filing a ticket for a project. Value is either a literal or a
+// text/template referencing the same data passed to the summary and
+// description templates (e.g. "{{.Task.BuildVariant}}").
+type FieldSpec struct {
+	Type  string `yaml:"type"` // one of "string", "array", "user", "option"
+	Value string `yaml:"value"`
+}
+
+// render evaluates the FieldSpec's Value as a template against data and
+// shapes the result into the form JIRA's REST API expects for Type.
+func (f FieldSpec) render(data interface{}) (interface{}, error) {
+	tmpl, err := template.New("field").Parse(f.Value)
+	if err != nil {
+		return nil, err
+	}
+	buf := &bytes.Buffer{}
+	if err := tmpl.Execute(buf, data); err != nil {
+		return nil, err
+	}
+	rendered := buf.String()
+
+	switch f.Type {
+	case "array":
+		return []string{rendered}, nil
+	case "user":
+		return map[string]string{"name": rendered}, nil
+	case "option":
+		return map[string]string{"value": rendered}, nil
+	case "string", "":
+		return rendered, nil
+	default:
+		return nil, fmt.Errorf("unrecognized custom field type %q", f.Type)
+	}
+}
+
+// ProjectConfig maps one Evergreen project onto a JIRA project/issue type
+// and the custom fields and templates its tickets should use. Build Baron
+// looks this up by t.Project, so every project that wants to use Build
+// Baron needs an entry in bbp.opts.Projects.
+type ProjectConfig struct {
+	JiraProject  string               `yaml:"jira_project"`
+	IssueType    string               `yaml:"issue_type"`
+	CustomFields map[string]FieldSpec `yaml:"custom_fields"`
+
+	// SummaryTemplate and DescriptionTemplate are text/template strings. If
+	// unset, the plugin's default summary heuristic and description markup
+	// are used instead.
+	SummaryTemplate     string `yaml:"summary_template"`
+	DescriptionTemplate string `yaml:"description_template"`
+
+	// RepoPath is the local git checkout used to suggest a ticket owner for
+	// this project's failures. If unset, the plugin-wide RepoPath is used
+	// instead - only set this when the project lives in a different repo
+	// than that default.
+	RepoPath string `yaml:"repo_path"`
+}
+
+// descriptionArgs is the data made available to a project's custom field,
+// summary, and description templates.
+type descriptionArgs struct {
+	Task           *model.Task
+	UserId         string
+	Tests          []jiraTestFailure
+	ClusterSection string
+}
+
+// renderCustomFields evaluates every configured custom field against args.
+func renderCustomFields(fields map[string]FieldSpec, args descriptionArgs) (map[string]interface{}, error) {
+	rendered := map[string]interface{}{}
+	for key, spec := range fields {
+		value, err := spec.render(args)
+		if err != nil {
+			return nil, fmt.Errorf("rendering custom field %v: %v", key, err)
+		}
+		rendered[key] = value
+	}
+	return rendered, nil
+}
+
+// renderSummary renders cfg's summary template if it has one, otherwise
+// falls back to the default length-based summary heuristic.
+func renderSummary(cfg ProjectConfig, taskName string, tests []jiraTestFailure, args descriptionArgs) (string, error) {
+	if cfg.SummaryTemplate == "" {
+		return getSummary(taskName, tests), nil
+	}
+	tmpl, err := template.New("summary").Parse(cfg.SummaryTemplate)
+	if err != nil {
+		return "", fmt.Errorf("parsing summary template: %v", err)
+	}
+	buf := &bytes.Buffer{}
+	if err := tmpl.Execute(buf, args); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// renderProjectDescription renders cfg's description template if it has
+// one, otherwise falls back to the plugin's default backend-appropriate
+// description markup.
+func renderProjectDescription(cfg ProjectConfig, backend Backend, args descriptionArgs) (string, error) {
+	tmpl := descriptionTemplateFor(backend)
+	if cfg.DescriptionTemplate != "" {
+		var err error
+		if tmpl, err = template.New("description").Parse(cfg.DescriptionTemplate); err != nil {
+			return "", fmt.Errorf("parsing description template: %v", err)
+		}
+	}
+	buf := &bytes.Buffer{}
+	if err := tmpl.Execute(buf, args); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
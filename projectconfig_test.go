@@ -0,0 +1,116 @@
+package buildbaron
+
+import (
+	"github.com/evergreen-ci/evergreen/model"
+	"testing"
+)
+
+func TestFieldSpecRender(t *testing.T) {
+	args := descriptionArgs{
+		Task: &model.Task{DisplayName: "my_task", BuildVariant: "ubuntu"},
+	}
+
+	cases := []struct {
+		name string
+		spec FieldSpec
+		want interface{}
+	}{
+		{"literal", FieldSpec{Type: "string", Value: "literal-value"}, "literal-value"},
+		{"default type is string", FieldSpec{Value: "literal-value"}, "literal-value"},
+		{"task field reference", FieldSpec{Type: "string", Value: "{{.Task.BuildVariant}}"}, "ubuntu"},
+		{"array", FieldSpec{Type: "array", Value: "{{.Task.DisplayName}}"}, []string{"my_task"}},
+		{"user", FieldSpec{Type: "user", Value: "{{.Task.DisplayName}}"}, map[string]string{"name": "my_task"}},
+		{"option", FieldSpec{Type: "option", Value: "{{.Task.DisplayName}}"}, map[string]string{"value": "my_task"}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := c.spec.render(args)
+			if err != nil {
+				t.Fatalf("render() returned error: %v", err)
+			}
+			if !equalRendered(got, c.want) {
+				t.Errorf("render() = %#v, want %#v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestFieldSpecRenderRejectsUnknownType(t *testing.T) {
+	_, err := FieldSpec{Type: "bogus", Value: "x"}.render(descriptionArgs{})
+	if err == nil {
+		t.Fatal("expected an error for an unrecognized field type, got nil")
+	}
+}
+
+func TestRenderCustomFields(t *testing.T) {
+	args := descriptionArgs{Task: &model.Task{DisplayName: "my_task"}}
+	fields := map[string]FieldSpec{
+		"customfield_12950": {Type: "array", Value: "{{.Task.DisplayName}}"},
+	}
+	rendered, err := renderCustomFields(fields, args)
+	if err != nil {
+		t.Fatalf("renderCustomFields returned error: %v", err)
+	}
+	got, ok := rendered["customfield_12950"].([]string)
+	if !ok || len(got) != 1 || got[0] != "my_task" {
+		t.Errorf("renderCustomFields()[customfield_12950] = %#v, want []string{\"my_task\"}", rendered["customfield_12950"])
+	}
+}
+
+func TestRenderSummaryFallsBackToDefault(t *testing.T) {
+	cfg := ProjectConfig{}
+	tests := []jiraTestFailure{{Name: "foo_test"}}
+	got, err := renderSummary(cfg, "my_task", tests, descriptionArgs{})
+	if err != nil {
+		t.Fatalf("renderSummary returned error: %v", err)
+	}
+	want := getSummary("my_task", tests)
+	if got != want {
+		t.Errorf("renderSummary() = %q, want default summary %q", got, want)
+	}
+}
+
+func TestRenderSummaryUsesProjectTemplate(t *testing.T) {
+	cfg := ProjectConfig{SummaryTemplate: "custom: {{.Task.DisplayName}}"}
+	args := descriptionArgs{Task: &model.Task{DisplayName: "my_task"}}
+	got, err := renderSummary(cfg, "my_task", nil, args)
+	if err != nil {
+		t.Fatalf("renderSummary returned error: %v", err)
+	}
+	if want := "custom: my_task"; got != want {
+		t.Errorf("renderSummary() = %q, want %q", got, want)
+	}
+}
+
+// equalRendered compares the handful of shapes FieldSpec.render can produce.
+func equalRendered(a, b interface{}) bool {
+	switch want := b.(type) {
+	case string:
+		got, ok := a.(string)
+		return ok && got == want
+	case []string:
+		got, ok := a.([]string)
+		if !ok || len(got) != len(want) {
+			return false
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				return false
+			}
+		}
+		return true
+	case map[string]string:
+		got, ok := a.(map[string]string)
+		if !ok || len(got) != len(want) {
+			return false
+		}
+		for k, v := range want {
+			if got[k] != v {
+				return false
+			}
+		}
+		return true
+	default:
+		return false
+	}
+}
@@ -1,7 +1,6 @@
 package buildbaron
 
 import (
-	"bytes"
 	"encoding/json"
 	"fmt"
 	"github.com/10gen-labs/slogger/v1"
@@ -9,22 +8,27 @@ import (
 	"github.com/evergreen-ci/evergreen/model"
 	"github.com/evergreen-ci/evergreen/plugin"
 	"github.com/evergreen-ci/evergreen/thirdparty"
+	"github.com/gorilla/mux"
 	"net/http"
+	"strconv"
 	"strings"
 	"text/template"
 )
 
-const FailingTasksField = "customfield_12950"
-
 const UIRoot = "https://evergreen.mongodb.com"
 
+// DescriptionTemplateString is the default JIRA wiki markup description used,
+// on the JIRA backend, for projects whose ProjectConfig doesn't set its own
+// DescriptionTemplate. GitHub and GitLab use MarkdownDescriptionTemplate.
 const DescriptionTemplateString = `
 h2. [{{.Task.DisplayName}} failed on {{.Task.BuildVariant}}|` + UIRoot + `/task/{{.Task.Id}}]
 
 {{range .Tests}}*{{.Name}}* - [Logs|{{.URL}}] | [History|{{.HistoryURL}}]
-
+{{if .Snippet}}
+{{.Snippet}}
 {{end}}
-
+{{end}}
+{{.ClusterSection}}
 
 
 ~BF Ticket Generated by [~{{.UserId}}]~
@@ -37,13 +41,24 @@ type jiraTestFailure struct {
 	Name       string
 	URL        string
 	HistoryURL string
+	// LogTail holds the last lines of this test's log, fetched from its log
+	// URL for attachment to the ticket. Empty if the fetch failed or wasn't
+	// attempted - description rendering must tolerate that.
+	LogTail []string
+	// Snippet is LogTail rendered as a backend-appropriate fenced code
+	// block, ready to inline into the description.
+	Snippet string
 }
 
-// fileTicket creates a JIRA ticket for a task with the given test failures.
+// fileTicket creates a ticket, in the backend selected by bbp.opts.Backend,
+// for a task with the given test failures. If the request includes a link_to
+// issue key, the task is linked to that existing JIRA ticket instead of
+// creating a new one.
 func (bbp *BuildBaronPlugin) fileTicket(w http.ResponseWriter, r *http.Request) {
 	var input struct {
 		TaskId  string   `json:"task"`
 		TestIds []string `json:"tests"`
+		LinkTo  string   `json:"link_to"`
 	}
 	json.NewDecoder(r.Body).Decode(&input)
 
@@ -62,6 +77,11 @@ func (bbp *BuildBaronPlugin) fileTicket(w http.ResponseWriter, r *http.Request)
 		plugin.WriteJSON(w, http.StatusNotFound, fmt.Sprintf("task not found for id %v", input.TaskId))
 		return
 	}
+	cfg, ok := bbp.opts.Projects[t.Project]
+	if !ok {
+		plugin.WriteJSON(w, http.StatusBadRequest, fmt.Sprintf("no build baron configuration for project %v", t.Project))
+		return
+	}
 
 	// build a list of all failed tests to include
 	testIds := map[string]bool{}
@@ -71,45 +91,202 @@ func (bbp *BuildBaronPlugin) fileTicket(w http.ResponseWriter, r *http.Request)
 	tests := []jiraTestFailure{}
 	for _, test := range t.TestResults {
 		if testIds[test.TestFile] {
-			tests = append(tests, jiraTestFailure{
+			failure := jiraTestFailure{
 				Name:       cleanTestName(test.TestFile),
 				URL:        test.URL,
 				HistoryURL: historyURL(t, cleanTestName(test.TestFile)),
-			})
+			}
+			// fetching the log tail is best-effort: if it fails, the ticket
+			// still gets filed with a link-only description for this test.
+			if failure.URL != "" {
+				if tail, err := fetchLogTail(failure.URL, logTailLines(bbp)); err != nil {
+					evergreen.Logger.Logf(slogger.WARN, fmt.Sprintf("error fetching log for %v: %v", failure.Name, err))
+				} else {
+					failure.LogTail = tail
+					failure.Snippet = logSnippet(bbp.opts.Backend, tail)
+				}
+			}
+			tests = append(tests, failure)
 		}
 	}
 
-	//lay out the JIRA API request
-	request := map[string]interface{}{}
-	request["project"] = map[string]string{"key": "BF"}
-	request["summary"] = getSummary(t.DisplayName, tests)
-	request[FailingTasksField] = []string{t.DisplayName}
-	request["issuetype"] = map[string]string{"name": "Build Failure"}
-	request["assignee"] = map[string]string{"name": u.Id}
-	request["reporter"] = map[string]string{"name": u.Id}
-	request["description"], err = getDescription(t, u.Id, tests)
+	ts, err := bbp.ticketSystem()
+	if err != nil {
+		plugin.WriteJSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// if the user chose to link to an existing ticket instead of filing a
+	// new one, do that and return early. Linking is JIRA-specific today: the
+	// "Failing Tasks" custom field only exists there.
+	if input.LinkTo != "" {
+		if bbp.opts.Backend != "" && bbp.opts.Backend != BackendJira {
+			plugin.WriteJSON(w, http.StatusBadRequest, "linking to an existing ticket requires the JIRA backend")
+			return
+		}
+		jiraHandler := thirdparty.NewJiraHandler(bbp.opts.Host, bbp.opts.Username, bbp.opts.Password)
+		if err = jiraHandler.LinkIssue(input.LinkTo, t.DisplayName); err != nil {
+			msg := fmt.Sprintf("error linking task to existing JIRA ticket %v: %v", input.LinkTo, err)
+			evergreen.Logger.Logf(slogger.ERROR, msg)
+			plugin.WriteJSON(w, http.StatusBadRequest, msg)
+			return
+		}
+		evergreen.Logger.Logf(slogger.INFO, fmt.Sprintf("Task %v linked to existing ticket %v", t.Id, input.LinkTo))
+		plugin.WriteJSON(w, http.StatusOK, struct {
+			Key string `json:"key"`
+		}{input.LinkTo})
+		return
+	}
+
+	cluster, err := clusterFailure(t, tests, bbp.revisionResolver(t.Project))
+	if err != nil {
+		// clustering is best-effort: a failure here shouldn't block filing
+		// the ticket, so just log it and carry on without suggestions.
+		evergreen.Logger.Logf(slogger.WARN, fmt.Sprintf("error clustering failure for task %v: %v", t.Id, err))
+		cluster = nil
+	}
+
+	args := descriptionArgs{
+		Task:           t,
+		UserId:         u.Id,
+		Tests:          tests,
+		ClusterSection: similarFailuresSection(bbp.opts.Backend, cluster),
+	}
+	description, err := renderProjectDescription(cfg, bbp.opts.Backend, args)
 	if err != nil {
 		plugin.WriteJSON(
 			w, http.StatusBadRequest, fmt.Sprintf("error creating description: %v", err))
 		return
 	}
+	summary, err := renderSummary(cfg, t.DisplayName, tests, args)
+	if err != nil {
+		plugin.WriteJSON(
+			w, http.StatusBadRequest, fmt.Sprintf("error creating summary: %v", err))
+		return
+	}
+	customFields, err := renderCustomFields(cfg.CustomFields, args)
+	if err != nil {
+		plugin.WriteJSON(
+			w, http.StatusBadRequest, fmt.Sprintf("error creating ticket: %v", err))
+		return
+	}
+
+	evergreen.Logger.Logf(slogger.INFO, fmt.Sprintf("Creating %v ticket for user %v", bbp.opts.Backend, u.Id))
+
+	result, err := ts.Create(TicketFields{
+		Summary:      summary,
+		Description:  description,
+		Project:      cfg.JiraProject,
+		IssueType:    cfg.IssueType,
+		FailingTasks: []string{t.DisplayName},
+		CustomFields: customFields,
+		AssigneeId:   u.Id,
+		ReporterId:   u.Id,
+	})
+	if err != nil {
+		msg := fmt.Sprintf("error creating ticket: %v", err)
+		evergreen.Logger.Logf(slogger.ERROR, msg)
+		plugin.WriteJSON(w, http.StatusBadRequest, msg)
+		return
+	}
+	evergreen.Logger.Logf(slogger.INFO, fmt.Sprintf("Ticket %v successfully created", result.Key))
+
+	// attaching full logs is JIRA-specific and best-effort: the ticket
+	// already exists, so a failed attachment just gets logged.
+	if jts, ok := ts.(*jiraTicketSystem); ok {
+		attachTestLogs(jts, result.Key, tests)
+	}
+
+	plugin.WriteJSON(w, http.StatusOK, result)
+}
 
-	evergreen.Logger.Logf(slogger.INFO, fmt.Sprintf("Creating JIRA ticket for user %v", u.Id))
+// existingTicketsJQL returns the JQL query used to search for open Build
+// Failure tickets already filed against the given failing task in the given
+// JIRA project.
+func existingTicketsJQL(jiraProject, taskName string) string {
+	return fmt.Sprintf(
+		`project = %v AND "Failing Tasks" = %v AND status in (Open, "In Progress")`,
+		strconv.Quote(jiraProject), strconv.Quote(taskName))
+}
 
+// searchTickets looks for existing Build Failure tickets that reference the
+// given task, so that fileTicket callers can offer to link to one instead of
+// filing a duplicate. Searching for duplicates is JIRA-specific today, the
+// same as linking to an existing ticket in fileTicket - callers are expected
+// to check bbp.opts.Backend before calling this, the way existingTickets does.
+func (bbp *BuildBaronPlugin) searchTickets(t *model.Task, jiraProject string) ([]thirdparty.JiraTicket, error) {
 	jiraHandler := thirdparty.NewJiraHandler(
 		bbp.opts.Host,
 		bbp.opts.Username,
 		bbp.opts.Password,
 	)
-	result, err := jiraHandler.CreateTicket(request)
+	issues, err := jiraHandler.JQLSearch(existingTicketsJQL(jiraProject, t.DisplayName))
 	if err != nil {
-		msg := fmt.Sprintf("error creating JIRA ticket: %v", err)
-		evergreen.Logger.Logf(slogger.ERROR, msg)
-		plugin.WriteJSON(w, http.StatusBadRequest, msg)
+		return nil, fmt.Errorf("searching JIRA for existing tickets: %v", err)
+	}
+
+	testNames := map[string]bool{}
+	for _, test := range t.TestResults {
+		if test.Status == "fail" {
+			testNames[cleanTestName(test.TestFile)] = true
+		}
+	}
+
+	matches := []thirdparty.JiraTicket{}
+	for _, issue := range issues {
+		if fuzzyMatchesFailingTests(issue, testNames) {
+			matches = append(matches, issue)
+		}
+	}
+	return matches, nil
+}
+
+// fuzzyMatchesFailingTests returns true if any failing test name referenced
+// by the ticket's summary or description appears in testNames. An empty
+// testNames matches every ticket.
+func fuzzyMatchesFailingTests(issue thirdparty.JiraTicket, testNames map[string]bool) bool {
+	if len(testNames) == 0 {
+		return true
+	}
+	haystack := strings.ToLower(issue.Fields.Summary + " " + issue.Fields.Description)
+	for name := range testNames {
+		if name != "" && strings.Contains(haystack, strings.ToLower(name)) {
+			return true
+		}
+	}
+	return false
+}
+
+// existingTickets handles GET /plugin/buildbaron/existing/:task_id, returning
+// any open Build Failure tickets that look like they already cover this
+// task's failures.
+func (bbp *BuildBaronPlugin) existingTickets(w http.ResponseWriter, r *http.Request) {
+	taskId := mux.Vars(r)["task_id"]
+	t, err := model.FindTask(taskId)
+	if err != nil {
+		plugin.WriteJSON(w, http.StatusInternalServerError, err.Error())
 		return
 	}
-	evergreen.Logger.Logf(slogger.INFO, fmt.Sprintf("Ticket %v successfully created", result.Key))
-	plugin.WriteJSON(w, http.StatusOK, result)
+	if t == nil {
+		plugin.WriteJSON(w, http.StatusNotFound, fmt.Sprintf("task not found for id %v", taskId))
+		return
+	}
+	cfg, ok := bbp.opts.Projects[t.Project]
+	if !ok {
+		plugin.WriteJSON(w, http.StatusBadRequest, fmt.Sprintf("no build baron configuration for project %v", t.Project))
+		return
+	}
+	if bbp.opts.Backend != "" && bbp.opts.Backend != BackendJira {
+		plugin.WriteJSON(w, http.StatusBadRequest, "searching for existing tickets requires the JIRA backend")
+		return
+	}
+
+	tickets, err := bbp.searchTickets(t, cfg.JiraProject)
+	if err != nil {
+		plugin.WriteJSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	plugin.WriteJSON(w, http.StatusOK, tickets)
 }
 
 func cleanTestName(path string) string {
@@ -151,16 +328,3 @@ func getSummary(taskName string, tests []jiraTestFailure) string {
 		return strings.Join(names, ", ")
 	}
 }
-
-func getDescription(t *model.Task, userId string, tests []jiraTestFailure) (string, error) {
-	args := struct {
-		Task   *model.Task
-		UserId string
-		Tests  []jiraTestFailure
-	}{t, userId, tests}
-	buf := &bytes.Buffer{}
-	if err := DescriptionTemplate.Execute(buf, args); err != nil {
-		return "", err
-	}
-	return buf.String(), nil
-}
@@ -0,0 +1,58 @@
+package buildbaron
+
+import (
+	"github.com/evergreen-ci/evergreen/thirdparty"
+	"testing"
+)
+
+func TestFuzzyMatchesFailingTests(t *testing.T) {
+	issue := thirdparty.JiraTicket{Fields: thirdparty.JiraTicketFields{
+		Summary:     "foo_test failures on ubuntu",
+		Description: "seen in bar_test too",
+	}}
+
+	cases := []struct {
+		name      string
+		testNames map[string]bool
+		want      bool
+	}{
+		{"matches summary", map[string]bool{"foo_test": true}, true},
+		{"matches description", map[string]bool{"bar_test": true}, true},
+		{"matches is case insensitive", map[string]bool{"Foo_Test": true}, true},
+		{"no match", map[string]bool{"baz_test": true}, false},
+		{"empty testNames matches anything", map[string]bool{}, true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := fuzzyMatchesFailingTests(issue, c.testNames); got != c.want {
+				t.Errorf("fuzzyMatchesFailingTests() = %v, want %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestFuzzyMatchesFailingTestsIgnoresPassingTests(t *testing.T) {
+	// regression test: a ticket that only mentions a passing test's name
+	// must not be treated as a match for this task's failures. The loop below
+	// keeps only failing tests' names, the same filter searchTickets applies
+	// before calling fuzzyMatchesFailingTests, which itself never sees status.
+	issue := thirdparty.JiraTicket{Fields: thirdparty.JiraTicketFields{
+		Summary: "baz_test failure",
+	}}
+	testNames := map[string]bool{}
+	for _, test := range []struct {
+		name   string
+		status string
+	}{
+		{"foo_test", "pass"},
+		{"baz_test", "pass"},
+		{"bar_test", "fail"},
+	} {
+		if test.status == "fail" {
+			testNames[test.name] = true
+		}
+	}
+	if fuzzyMatchesFailingTests(issue, testNames) {
+		t.Errorf("fuzzyMatchesFailingTests() = true, want false: ticket only mentions a passing test")
+	}
+}